@@ -5,11 +5,18 @@ import (
 	"gopolodb/pkg/polodb"
 )
 
+type Person struct {
+	Name string `polodb:"name"`
+	Age  int    `polodb:"age"`
+}
+
 func main() {
-	db, _ := polodb.CreateDb()
-	db.CreateCollection("col")
-	//db.Insert(map[string]interface{}{"name": "joseph", "age": "13"})
-	res, _ := db.Find(map[string]interface{}{"name": "joseph"})
-	fmt.Println(res["name"])
+	db, _ := polodb.Open("/tmp/polodb.db", nil)
+	col, _ := db.CreateCollection("col")
+	col.Insert(&Person{Name: "joseph", Age: 13})
+
+	var res Person
+	col.FindOne(map[string]interface{}{"name": "joseph"}, &res)
+	fmt.Println(res.Name)
 	db.Close()
 }
@@ -0,0 +1,127 @@
+package polodb
+
+/*
+#cgo CFLAGS: -I../../lib
+#cgo LDFLAGS: -L../../lib -lpolodb
+#include "../../lib/polodb.h"
+*/
+import "C"
+import (
+	"errors"
+	"reflect"
+)
+
+// Cursor streams the rows produced by a query without buffering the whole
+// result set in memory. A Cursor must be closed once the caller is done
+// with it, including on error paths.
+type Cursor struct {
+	handle  *C.DbHandle
+	started bool
+	err     error
+	closed  bool
+	// unlock, if set, is called once when the cursor is closed. DBCollection.FindCursor
+	// uses this to hold its mutex for the cursor's whole lifetime, since the
+	// underlying DbHandle isn't safe to step concurrently with other calls.
+	unlock func()
+}
+
+func newCursor(handle *C.DbHandle) *Cursor {
+	return &Cursor{handle: handle}
+}
+
+// Next advances the cursor to the next row and reports whether one is
+// available. It returns false both when the result set is exhausted and
+// when an error occurred; call Err to tell the two apart.
+//
+// PLDB_find leaves the handle un-stepped, so PLDB_step must run before
+// the handle's state says anything about a row being available - on the
+// very first call too, not just subsequent ones.
+func (c *Cursor) Next() bool {
+	if c.closed || c.err != nil {
+		return false
+	}
+	if errCode := C.PLDB_step(c.handle); errCode != C.int(0) {
+		c.err = errors.New("Error advancing cursor\n")
+		return false
+	}
+	c.started = true
+	return C.PLDB_handle_state(c.handle) == DB_HANDLE_STATE_HAS_ROW
+}
+
+// Decode unmarshals the current row into v, which must be a non-nil
+// pointer to a struct.
+func (c *Cursor) Decode(v interface{}) error {
+	if !c.started {
+		return errors.New("polodb: Decode called before Next\n")
+	}
+	var val *C.DbValue
+	C.PLDB_handle_get(c.handle, &val)
+	defer C.PLDB_free_value(val)
+	return Unmarshal(val, v)
+}
+
+// Err returns the first error encountered while iterating, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close releases the underlying DbHandle. It is safe to call multiple
+// times and after an error.
+func (c *Cursor) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	C.PLDB_free_handle(c.handle)
+	if c.unlock != nil {
+		c.unlock()
+	}
+	return nil
+}
+
+// FindCursor runs filter against the collection and returns a Cursor over
+// the matching rows. The caller must Close it.
+func (c *Collection) FindCursor(filter interface{}) (*Cursor, error) {
+	doc, err := Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	defer C.PLDB_free_doc(doc)
+
+	var handle *C.DbHandle
+	if errCode := C.PLDB_find(c.db, c.id, c.metaVer, doc, &handle); errCode != C.int(0) {
+		return nil, errors.New("Error searching into database\n")
+	}
+	return newCursor(handle), nil
+}
+
+// FindAll runs filter against the collection and decodes every matching
+// row into results, which must be a pointer to a slice of structs.
+func (c *Collection) FindAll(filter interface{}, results interface{}) error {
+	rv := reflect.ValueOf(results)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("polodb: FindAll requires a non-nil pointer to a slice\n")
+	}
+	sliceVal := rv.Elem()
+	elemType := sliceVal.Type().Elem()
+
+	cur, err := c.FindCursor(filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	out := reflect.MakeSlice(sliceVal.Type(), 0, 0)
+	for cur.Next() {
+		elem := reflect.New(elemType)
+		if err := cur.Decode(elem.Interface()); err != nil {
+			return err
+		}
+		out = reflect.Append(out, elem.Elem())
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	sliceVal.Set(out)
+	return nil
+}
@@ -8,8 +8,8 @@ package polodb
 import "C"
 import (
 	"errors"
-	"os"
 	"time"
+	"unsafe"
 )
 
 const (
@@ -35,77 +35,93 @@ const (
 	KEY_OBJECT_ID = "ObjectId"
 )
 
-//export
-func CreateDb() (*C.Database, error) {
-	database := C.PLDB_open(C.CString("/tmp/polodb.db"))
-	if database == nil {
-		return nil, errors.New("Error while creating database\n")
-	}
-	return database, nil
+// Collection is a handle to one named collection within a database,
+// obtained from CreateCollection or Collection. Every read/write method
+// targets the colId/metaVersion pair this handle was opened with, instead
+// of assuming the database has exactly one collection.
+type Collection struct {
+	db      *C.Database
+	name    string
+	id      C.uint
+	metaVer C.uint
 }
 
-func (db *C.Database) CreateCollection(colName string) error {
+// CreateCollection creates colName and returns a handle to it.
+func (db *C.Database) CreateCollection(colName string) (*Collection, error) {
+	cColName := C.CString(colName)
+	defer C.free(unsafe.Pointer(cColName))
+
 	colId := C.uint(0)
 	metaVersion := C.uint(1)
-	errCode := C.PLDB_create_collection(db, C.CString(colName), &colId, &metaVersion)
+	errCode := C.PLDB_create_collection(db, cColName, &colId, &metaVersion)
 	if errCode != C.int(1) {
-		return errors.New("Error while creating collection\n")
+		return nil, errors.New("Error while creating collection\n")
 	}
-	return nil
+	return &Collection{db: db, name: colName, id: colId, metaVer: metaVersion}, nil
 }
 
-func (db *C.Database) DeleteDb() error {
-	if db != nil {
-		C.PLDB_close(db)
-		os.Remove("/tmp/polodb.db")
-		return nil
-	} else {
-		return errors.New("Database is not opened\n")
+// Collection looks up a collection that was created in an earlier
+// session and returns a handle to it.
+func (db *C.Database) Collection(colName string) (*Collection, error) {
+	cColName := C.CString(colName)
+	defer C.free(unsafe.Pointer(cColName))
+
+	colId := C.uint(0)
+	metaVersion := C.uint(0)
+	errCode := C.PLDB_get_collection_meta_by_name(db, cColName, &colId, &metaVersion)
+	if errCode < 0 {
+		return nil, errors.New("Error while looking up collection: " + colName + "\n")
 	}
+	return &Collection{db: db, name: colName, id: colId, metaVer: metaVersion}, nil
 }
 
-func (db *C.Database) Find(arguments map[string]interface{}) (map[string]interface{}, error) {
-	doc, err := createDocument(arguments)
-	res := make(map[string]interface{})
+// Find runs filter against the collection and decodes every matching
+// document into result, in order. Each match overwrites the previous one,
+// so on return result holds only the last row PoloDB produced. Use
+// FindOne for a single row, or FindAll/FindCursor (see cursor.go) to
+// collect or stream through all of them without this loss.
+func (c *Collection) Find(filter interface{}, result interface{}) error {
+	cur, err := c.FindCursor(filter)
 	if err != nil {
-		return nil, errors.New("Error while creating document\n")
+		return err
 	}
-	var handle *C.DbHandle
-	errCode := C.PLDB_find(db, 0, 1, doc, &handle)
-	if errCode != C.int(0) {
-		return nil, errors.New("Error searching into database\n")
+	defer cur.Close()
+
+	for cur.Next() {
+		if err := cur.Decode(result); err != nil {
+			return err
+		}
 	}
+	return cur.Err()
+}
 
-	errCode = C.PLDB_step(handle)
-	if errCode != C.int(0) {
-		return nil, errors.New("Error searching into database\n")
+// FindOne runs filter against the collection and decodes the first
+// matching document into result, stopping as soon as one is found.
+func (c *Collection) FindOne(filter interface{}, result interface{}) error {
+	cur, err := c.FindCursor(filter)
+	if err != nil {
+		return err
 	}
+	defer cur.Close()
 
-	for C.PLDB_handle_state(handle) == 2 {
-		var val *C.DbValue
-		C.PLDB_handle_get(handle, &val)
-		res, err = documentToObj(val, res)
-		if err != nil {
-			return nil, errors.New("Error searching into database\n")
-		}
-		C.PLDB_free_value(val)
-		errCode = C.PLDB_step(handle)
-		if errCode != C.int(0) {
-			return nil, errors.New("Error searching into database\n")
+	if !cur.Next() {
+		if err := cur.Err(); err != nil {
+			return err
 		}
+		return errors.New("No document found\n")
 	}
-	defer C.PLDB_free_handle(handle)
-	defer C.PLDB_free_doc(doc)
-	return res, nil
+	return cur.Decode(result)
 }
 
-func (db *C.Database) Insert(values map[string]interface{}) error {
-	doc, err := createDocument(values)
+// Insert encodes values (a struct, a pointer to a struct, or a
+// map[string]interface{}) and stores it in the collection.
+func (c *Collection) Insert(values interface{}) error {
+	doc, err := Marshal(values)
 	if err != nil {
-		return errors.New("Error while creating document\n")
+		return err
 	}
-	errCode := C.PLDB_insert(db, 0, 1, doc)
 	defer C.PLDB_free_doc(doc)
+	errCode := C.PLDB_insert(c.db, c.id, c.metaVer, doc)
 	if errCode != C.int(0) {
 		return errors.New("Error inserting into database\n")
 	}
@@ -116,88 +132,6 @@ func (db *C.Database) Close() {
 	C.PLDB_close(db)
 }
 
-func createDocument(values map[string]interface{}) (*C.DbDocument, error) {
-	doc := C.PLDB_mk_doc()
-	if values == nil {
-		return nil, errors.New("Empty map given\n")
-	}
-
-	for key, value := range values {
-		err := doc.setProperty(key, value)
-		if err != nil {
-			C.PLDB_free_doc(doc)
-			return nil, errors.New("Error while inserting key: " + key + "\n")
-		}
-	}
-	return doc, nil
-}
-
-func (doc *C.DbDocument) setProperty(key string, value interface{}) error {
-	var errCode C.int
-	switch value.(type) {
-	case string:
-		errCode = C.PLDB_doc_set_string(doc, C.CString(key), C.CString(value.(string)))
-	case time.Time:
-		errCode = C.PLDB_doc_set_UTCDateTime(doc, C.CString(key), C.longlong(value.(time.Time).Unix()))
-	case int:
-		errCode = C.PLDB_doc_set_int(doc, C.CString(key), C.longlong(value.(int)))
-	default:
-		errCode = -1
-	}
-
-	switch errCode {
-	case -1:
-		return errors.New("Unsupported type\n")
-	case 0:
-		return nil
-	default:
-		return errors.New("Error while setting document property\n")
-	}
-}
-
-func documentToObj(val *C.DbValue, keyStr map[string]interface{}) (map[string]interface{}, error) {
-	var doc *C.DbDocument
-	resCode := C.PLDB_value_get_document(val, &doc)
-	if resCode < 0 {
-		return map[string]interface{}{}, errors.New("DbValue get document error\n")
-	}
-	var keyBuf = C.CString("")
-	var tempVal *C.DbValue
-	iterObj := C.PLDB_doc_iter(doc)
-	for C.PLDB_doc_iter_next(iterObj, keyBuf, 512, &tempVal) > C.int(0) {
-		var value interface{}
-		switch C.PLDB_value_type(tempVal) {
-		case PLDB_VAL_DOUBL:
-			value, _ = doubleToObj(tempVal)
-		case PLDB_VAL_BOOLEAN:
-			value, _ = booleanToObj(tempVal)
-		case PLDB_VAL_ARRAY:
-			value, _ = arrayToObj(tempVal)
-		case PLDB_VAL_NULL:
-			value = nil
-		case PLDB_VAL_OBJECT_ID:
-			value, _ = objIdToObj(tempVal)
-		case PLDB_VAL_STRING:
-			value, _ = stringToObj(tempVal)
-		case PLDB_VAL_INT:
-			value, _ = intToObj(tempVal)
-		case PLDB_VAL_UTC_DATETIME:
-			value, _ = timeToObj(tempVal)
-		default:
-			return nil, errors.New("Type not supported\n")
-		}
-		keyStr[C.GoString(keyBuf)] = value
-		C.PLDB_free_value(tempVal)
-	}
-	C.PLDB_free_doc_iter(iterObj)
-	C.PLDB_free_doc(doc)
-	return keyStr, nil
-}
-
-func valueToObj(val *C.DbValue) interface{} {
-	return nil
-}
-
 func stringToObj(val *C.DbValue) (string, error) {
 	var resString *C.char
 	resCode := C.PLDB_value_get_string_utf8(val, &resString)
@@ -227,15 +161,6 @@ func timeToObj(val *C.DbValue) (time.Time, error) {
 	return time.Unix(int64(timeStamp), 0), nil
 }
 
-func objIdToObj(val *C.DbValue) (*C.DbObjectId, error) {
-	var res *C.DbObjectId
-	resCode := C.PLDB_value_get_object_id(val, &res)
-	if resCode < 0 {
-		return nil, errors.New("DbValue get object id error")
-	}
-	return res, nil
-}
-
 func doubleToObj(val *C.DbValue) (float64, error) {
 	var res C.double
 	resCode := C.PLDB_value_get_double(val, &res)
@@ -254,28 +179,3 @@ func booleanToObj(val *C.DbValue) (bool, error) {
 	return res, nil
 }
 
-func arrayToObj(val *C.DbValue) ([]interface{}, error){
-	var dbArr C.DbArray
-	var resArray []interface{}
-	resCode := C.PLDB_value_get_array(val, &dbArr)
-	if resCode < 0 {
-		return nil, errors.New("DbValue get array error")
-	}
-	arrLen := C.PLDB_arr_len(dbArr)
-	for i := 0; i < arrLen; i++ {
-		var tempVal C.DbValue
-		if C.PLDB_arr_get(dbArr, i, &tempVal) < 0 {
-			C.PLDB_free_arr(dbArr)
-			return nil, errors.New("DbValue get array error")
-		}
-
-		item := valueToObj(tempVal)
-		if item == nil {
-			return nil, errors.New("DbValue get array error")
-		}
-		resArray = append(resArray, item)
-		C.PLDB_free_value(tempVal)
-	}
-	C.PLDB_free_arr(dbArr)
-	return resArray, nil
-}
\ No newline at end of file
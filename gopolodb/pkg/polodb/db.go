@@ -0,0 +1,233 @@
+package polodb
+
+/*
+#cgo CFLAGS: -I../../lib
+#cgo LDFLAGS: -L../../lib -lpolodb
+#include "../../lib/polodb.h"
+*/
+import "C"
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Options configures how Open connects to a database file.
+type Options struct {
+	// ReadOnly rejects any write through the returned DB.
+	ReadOnly bool
+	// Timeout bounds how long Open waits for the database file to become
+	// available. Zero means wait forever.
+	Timeout time.Duration
+}
+
+// DB is a handle to a single PoloDB file. The underlying *C.Database is
+// not reentrant, so DB serializes access to it with a mutex; opening
+// several files concurrently just means holding several *DB values, each
+// with its own handle, path, and mutex.
+type DB struct {
+	mu       sync.Mutex
+	db       *C.Database
+	path     string
+	readOnly bool
+}
+
+// Open opens (creating if necessary) the database file at path. opts may
+// be nil to accept PoloDB's defaults.
+func Open(path string, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	type openResult struct {
+		db  *C.Database
+		err error
+	}
+	done := make(chan openResult, 1)
+	go func() {
+		cPath := C.CString(path)
+		defer C.free(unsafe.Pointer(cPath))
+		database := C.PLDB_open(cPath)
+		if database == nil {
+			done <- openResult{nil, errors.New("Error while opening database: " + path + "\n")}
+			return
+		}
+		done <- openResult{database, nil}
+	}()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timeout = time.After(opts.Timeout)
+	}
+
+	var res openResult
+	select {
+	case res = <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+	case <-timeout:
+		// PLDB_open may still succeed after we give up waiting on it; drain
+		// the result in the background so that late handle isn't leaked.
+		go func() {
+			if late := <-done; late.db != nil {
+				C.PLDB_close(late.db)
+			}
+		}()
+		return nil, errors.New("Timed out opening database: " + path + "\n")
+	}
+
+	return &DB{db: res.db, path: path, readOnly: opts.ReadOnly}, nil
+}
+
+func (d *DB) checkWritable() error {
+	if d.readOnly {
+		return errors.New("Database " + d.path + " was opened read-only\n")
+	}
+	return nil
+}
+
+// Path returns the file path this DB was opened with.
+func (d *DB) Path() string {
+	return d.path
+}
+
+// Close releases the underlying database handle without removing the
+// file on disk.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db == nil {
+		return errors.New("Database is not opened\n")
+	}
+	C.PLDB_close(d.db)
+	d.db = nil
+	return nil
+}
+
+// Drop closes the database and deletes its backing file.
+func (d *DB) Drop() error {
+	if err := d.Close(); err != nil {
+		return err
+	}
+	return os.Remove(d.path)
+}
+
+// DBCollection is a mutex-guarded handle to one named collection in a DB,
+// obtained from DB.CreateCollection or DB.Collection. It exposes the same
+// surface as Collection, serialized against the rest of d's callers.
+type DBCollection struct {
+	d   *DB
+	col *Collection
+}
+
+// CreateCollection creates colName in d and returns a handle to it.
+func (d *DB) CreateCollection(colName string) (*DBCollection, error) {
+	if err := d.checkWritable(); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	col, err := d.db.CreateCollection(colName)
+	if err != nil {
+		return nil, err
+	}
+	return &DBCollection{d: d, col: col}, nil
+}
+
+// Collection looks up a collection created in an earlier session and
+// returns a handle to it.
+func (d *DB) Collection(colName string) (*DBCollection, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	col, err := d.db.Collection(colName)
+	if err != nil {
+		return nil, err
+	}
+	return &DBCollection{d: d, col: col}, nil
+}
+
+func (c *DBCollection) Insert(values interface{}) error {
+	if err := c.d.checkWritable(); err != nil {
+		return err
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.Insert(values)
+}
+
+func (c *DBCollection) Find(filter interface{}, result interface{}) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.Find(filter, result)
+}
+
+func (c *DBCollection) FindOne(filter interface{}, result interface{}) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.FindOne(filter, result)
+}
+
+func (c *DBCollection) FindAll(filter interface{}, results interface{}) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.FindAll(filter, results)
+}
+
+// FindCursor runs filter and returns a Cursor over the matching rows. It
+// holds the DB's mutex until the cursor is closed, so callers must Close
+// it promptly to avoid blocking other goroutines using the same DB.
+func (c *DBCollection) FindCursor(filter interface{}) (*Cursor, error) {
+	c.d.mu.Lock()
+	cur, err := c.col.FindCursor(filter)
+	if err != nil {
+		c.d.mu.Unlock()
+		return nil, err
+	}
+	cur.unlock = c.d.mu.Unlock
+	return cur, nil
+}
+
+func (c *DBCollection) Update(filter interface{}, update interface{}) error {
+	if err := c.d.checkWritable(); err != nil {
+		return err
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.Update(filter, update)
+}
+
+func (c *DBCollection) Delete(filter interface{}) (int, error) {
+	if err := c.d.checkWritable(); err != nil {
+		return 0, err
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.Delete(filter)
+}
+
+func (c *DBCollection) Count(filter interface{}) (int, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.Count(filter)
+}
+
+func (c *DBCollection) CreateIndex(keys interface{}, opts *IndexOptions) error {
+	if err := c.d.checkWritable(); err != nil {
+		return err
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.CreateIndex(keys, opts)
+}
+
+func (c *DBCollection) DropIndex(indexName string) error {
+	if err := c.d.checkWritable(); err != nil {
+		return err
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.col.DropIndex(indexName)
+}
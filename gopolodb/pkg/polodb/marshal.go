@@ -0,0 +1,450 @@
+package polodb
+
+/*
+#cgo CFLAGS: -I../../lib
+#cgo LDFLAGS: -L../../lib -lpolodb
+#include "../../lib/polodb.h"
+*/
+import "C"
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+var objectIdType = reflect.TypeOf(ObjectId{})
+
+// field describes how a single struct field maps onto a document key.
+type field struct {
+	index     []int
+	name      string
+	omitempty bool
+}
+
+// structFields walks t's fields, honouring `polodb:"name,omitempty"` tags and
+// flattening anonymous embedded structs the way encoding/json does.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue // unexported
+		}
+
+		tag := sf.Tag.Get("polodb")
+		if tag == "-" {
+			continue
+		}
+
+		if sf.Anonymous && tag == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, embedded := range structFields(ft) {
+					embedded.index = append([]int{i}, embedded.index...)
+					fields = append(fields, embedded)
+				}
+				continue
+			}
+		}
+
+		name := sf.Name
+		omitempty := false
+		if tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitempty = true
+				}
+			}
+		}
+		fields = append(fields, field{index: []int{i}, name: name, omitempty: omitempty})
+	}
+	return fields
+}
+
+// Marshal converts v, which must be a struct, a pointer to a struct, or a
+// map[string]interface{}, into a *C.DbDocument understood by PLDB_insert and
+// PLDB_find. The caller owns the returned document and must release it with
+// C.PLDB_free_doc once it has been handed off.
+func Marshal(v interface{}) (*C.DbDocument, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, errors.New("polodb: Marshal called with nil pointer\n")
+		}
+		rv = rv.Elem()
+	}
+
+	doc := C.PLDB_mk_doc()
+	var err error
+	switch rv.Kind() {
+	case reflect.Struct:
+		err = marshalStruct(doc, rv)
+	case reflect.Map:
+		err = marshalMap(doc, rv)
+	default:
+		err = errors.New("polodb: Marshal does not support " + rv.Kind().String() + "\n")
+	}
+	if err != nil {
+		C.PLDB_free_doc(doc)
+		return nil, err
+	}
+	return doc, nil
+}
+
+func marshalStruct(doc *C.DbDocument, rv reflect.Value) error {
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndexForGet(rv, f.index)
+		if !ok {
+			continue // nil embedded pointer: field has no value to encode
+		}
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		if err := setDocField(doc, f.name, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndexForGet walks index the way reflect.Value.FieldByIndex does,
+// but reports ok=false instead of panicking when it has to dereference a
+// nil embedded pointer - a perfectly ordinary zero value for an optional
+// embedded struct, not a programmer error.
+func fieldByIndexForGet(v reflect.Value, index []int) (_ reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexForSet is fieldByIndexForGet's decode-side counterpart: it
+// allocates any nil embedded pointer it has to walk through, since the
+// caller is about to set a value somewhere underneath it.
+func fieldByIndexForSet(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func marshalMap(doc *C.DbDocument, rv reflect.Value) error {
+	if rv.Type().Key().Kind() != reflect.String {
+		return errors.New("polodb: Marshal only supports maps with string keys\n")
+	}
+	iter := rv.MapRange()
+	for iter.Next() {
+		if err := setDocField(doc, iter.Key().String(), iter.Value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDocField dereferences rv as needed and stores it under key in doc.
+func setDocField(doc *C.DbDocument, key string, rv reflect.Value) error {
+	for rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return setNull(doc, key)
+		}
+		rv = rv.Elem()
+	}
+
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	if t, ok := rv.Interface().(time.Time); ok {
+		errCode := C.PLDB_doc_set_UTCDateTime(doc, cKey, C.longlong(t.Unix()))
+		return cCheck(errCode, "set UTCDateTime field "+key)
+	}
+	if oid, ok := rv.Interface().(ObjectId); ok {
+		cOid := oid.toC()
+		defer C.PLDB_free_object_id(cOid)
+		return cCheck(C.PLDB_doc_set_object_id(doc, cKey, cOid), "set object id field "+key)
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		cVal := C.CString(rv.String())
+		defer C.free(unsafe.Pointer(cVal))
+		return cCheck(C.PLDB_doc_set_string(doc, cKey, cVal), "set string field "+key)
+	case reflect.Bool:
+		return cCheck(C.PLDB_doc_set_bool(doc, cKey, C.bool(rv.Bool())), "set bool field "+key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cCheck(C.PLDB_doc_set_int(doc, cKey, C.longlong(rv.Int())), "set int field "+key)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cCheck(C.PLDB_doc_set_int(doc, cKey, C.longlong(rv.Uint())), "set int field "+key)
+	case reflect.Float32, reflect.Float64:
+		return cCheck(C.PLDB_doc_set_double(doc, cKey, C.double(rv.Float())), "set double field "+key)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			bytes := rv.Bytes()
+			var ptr *C.char
+			if len(bytes) > 0 {
+				ptr = (*C.char)(unsafe.Pointer(&bytes[0]))
+			}
+			return cCheck(C.PLDB_doc_set_binary(doc, cKey, ptr, C.uint32_t(len(bytes))), "set binary field "+key)
+		}
+		arr, err := marshalSlice(rv)
+		if err != nil {
+			return err
+		}
+		defer C.PLDB_free_arr(arr)
+		return cCheck(C.PLDB_doc_set_arr(doc, cKey, arr), "set array field "+key)
+	case reflect.Struct:
+		sub := C.PLDB_mk_doc()
+		if err := marshalStruct(sub, rv); err != nil {
+			C.PLDB_free_doc(sub)
+			return err
+		}
+		defer C.PLDB_free_doc(sub)
+		return cCheck(C.PLDB_doc_set_doc(doc, cKey, sub), "set document field "+key)
+	case reflect.Map:
+		// Covers both sub-documents and query operator documents, e.g.
+		// {"age": {"$gt": 18}} or {"$and": [...]}: both are just nested
+		// documents as far as the marshaler is concerned.
+		sub := C.PLDB_mk_doc()
+		if err := marshalMap(sub, rv); err != nil {
+			C.PLDB_free_doc(sub)
+			return err
+		}
+		defer C.PLDB_free_doc(sub)
+		return cCheck(C.PLDB_doc_set_doc(doc, cKey, sub), "set document field "+key)
+	default:
+		return errors.New("polodb: unsupported field type for " + key + ": " + rv.Kind().String() + "\n")
+	}
+}
+
+func marshalSlice(rv reflect.Value) (C.DbArray, error) {
+	arr := C.PLDB_mk_arr()
+	for i := 0; i < rv.Len(); i++ {
+		if err := pushArrValue(arr, rv.Index(i)); err != nil {
+			C.PLDB_free_arr(arr)
+			return arr, err
+		}
+	}
+	return arr, nil
+}
+
+func pushArrValue(arr C.DbArray, rv reflect.Value) error {
+	tmpDoc := C.PLDB_mk_doc()
+	defer C.PLDB_free_doc(tmpDoc)
+	const wrapKey = "v"
+	if err := setDocField(tmpDoc, wrapKey, rv); err != nil {
+		return err
+	}
+	cKey := C.CString(wrapKey)
+	defer C.free(unsafe.Pointer(cKey))
+	var val *C.DbValue
+	if resCode := C.PLDB_doc_get(tmpDoc, cKey, &val); resCode < 0 {
+		return errors.New("polodb: failed to read back array element\n")
+	}
+	defer C.PLDB_free_value(val)
+	return cCheck(C.PLDB_arr_push(arr, val), "push array element")
+}
+
+func setNull(doc *C.DbDocument, key string) error {
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+	return cCheck(C.PLDB_doc_set_null(doc, cKey), "set null field "+key)
+}
+
+func cCheck(errCode C.int, what string) error {
+	if errCode < 0 {
+		return errors.New("polodb: error while " + what + "\n")
+	}
+	return nil
+}
+
+// Unmarshal decodes the document held by val into v, which must be a
+// non-nil pointer to a struct.
+func Unmarshal(val *C.DbValue, v interface{}) error {
+	var doc *C.DbDocument
+	if resCode := C.PLDB_value_get_document(val, &doc); resCode < 0 {
+		return errors.New("polodb: Unmarshal: value is not a document\n")
+	}
+	defer C.PLDB_free_doc(doc)
+	return unmarshalDoc(doc, v)
+}
+
+func unmarshalDoc(doc *C.DbDocument, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("polodb: Unmarshal requires a non-nil pointer\n")
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.New("polodb: Unmarshal requires a pointer to struct\n")
+	}
+
+	byName := make(map[string]field)
+	for _, f := range structFields(rv.Type()) {
+		byName[f.name] = f
+	}
+
+	keyBuf := make([]byte, 512)
+	iter := C.PLDB_doc_iter(doc)
+	defer C.PLDB_free_doc_iter(iter)
+	for {
+		cKeyBuf := (*C.char)(unsafe.Pointer(&keyBuf[0]))
+		var tempVal *C.DbValue
+		n := C.PLDB_doc_iter_next(iter, cKeyBuf, C.uint32_t(len(keyBuf)), &tempVal)
+		if n <= 0 {
+			break
+		}
+		key := C.GoString(cKeyBuf)
+		f, ok := byName[key]
+		if ok {
+			target := fieldByIndexForSet(rv, f.index)
+			if err := decodeValue(tempVal, target); err != nil {
+				C.PLDB_free_value(tempVal)
+				return err
+			}
+		}
+		C.PLDB_free_value(tempVal)
+	}
+	return nil
+}
+
+func decodeValue(val *C.DbValue, rv reflect.Value) error {
+	switch C.PLDB_value_type(val) {
+	case PLDB_VAL_NULL:
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	case PLDB_VAL_STRING:
+		s, err := stringToObj(val)
+		if err != nil {
+			return err
+		}
+		rv.SetString(s)
+		return nil
+	case PLDB_VAL_BOOLEAN:
+		b, err := booleanToObj(val)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+		return nil
+	case PLDB_VAL_INT:
+		i, err := intToObj(val)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(i))
+		return nil
+	case PLDB_VAL_DOUBL:
+		d, err := doubleToObj(val)
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(d)
+		return nil
+	case PLDB_VAL_UTC_DATETIME:
+		t, err := timeToObj(val)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case PLDB_VAL_BINARY:
+		b, err := binaryToObj(val)
+		if err != nil {
+			return err
+		}
+		rv.SetBytes(b)
+		return nil
+	case PLDB_VAL_OBJECT_ID:
+		id, err := objectIdFromValue(val)
+		if err != nil {
+			return err
+		}
+		switch {
+		case rv.Type() == objectIdType:
+			rv.Set(reflect.ValueOf(id))
+		case rv.Kind() == reflect.Ptr && rv.Type().Elem() == objectIdType:
+			rv.Set(reflect.ValueOf(&id))
+		default:
+			return errors.New("polodb: cannot decode object id into " + rv.Type().String() + "\n")
+		}
+		return nil
+	case PLDB_VAL_ARRAY:
+		return decodeArray(val, rv)
+	case PLDB_VAL_DOCUMENT:
+		if rv.Kind() != reflect.Struct {
+			return errors.New("polodb: cannot decode document into " + rv.Kind().String() + "\n")
+		}
+		var doc *C.DbDocument
+		if resCode := C.PLDB_value_get_document(val, &doc); resCode < 0 {
+			return errors.New("polodb: DbValue get document error\n")
+		}
+		defer C.PLDB_free_doc(doc)
+		return unmarshalDoc(doc, rv.Addr().Interface())
+	default:
+		return errors.New("polodb: Unmarshal: unsupported value type\n")
+	}
+}
+
+func decodeArray(val *C.DbValue, rv reflect.Value) error {
+	if rv.Kind() != reflect.Slice {
+		return errors.New("polodb: cannot decode array into " + rv.Kind().String() + "\n")
+	}
+	var dbArr C.DbArray
+	if resCode := C.PLDB_value_get_array(val, &dbArr); resCode < 0 {
+		return errors.New("polodb: DbValue get array error\n")
+	}
+	defer C.PLDB_free_arr(dbArr)
+
+	arrLen := int(C.PLDB_arr_len(dbArr))
+	out := reflect.MakeSlice(rv.Type(), arrLen, arrLen)
+	for i := 0; i < arrLen; i++ {
+		var tempVal C.DbValue
+		if C.PLDB_arr_get(dbArr, C.uint32_t(i), &tempVal) < 0 {
+			return errors.New("polodb: DbValue get array element error\n")
+		}
+		if err := decodeValue(&tempVal, out.Index(i)); err != nil {
+			C.PLDB_free_value(&tempVal)
+			return err
+		}
+		C.PLDB_free_value(&tempVal)
+	}
+	rv.Set(out)
+	return nil
+}
+
+func binaryToObj(val *C.DbValue) ([]byte, error) {
+	var ptr *C.char
+	var length C.uint32_t
+	resCode := C.PLDB_value_get_binary(val, &ptr, &length)
+	if resCode < 0 {
+		return nil, errors.New("DbValue get binary error")
+	}
+	return C.GoBytes(unsafe.Pointer(ptr), C.int(length)), nil
+}
@@ -0,0 +1,134 @@
+package polodb
+
+/*
+#cgo CFLAGS: -I../../lib
+#cgo LDFLAGS: -L../../lib -lpolodb
+#include "../../lib/polodb.h"
+*/
+import "C"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ObjectId is PoloDB's 12-byte document identifier: a 4-byte
+// seconds-since-epoch timestamp (big-endian), a 5-byte per-process random
+// value, and a 3-byte counter that increments for every id this process
+// generates.
+type ObjectId [12]byte
+
+var processUnique = func() [5]byte {
+	var b [5]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("polodb: failed to seed ObjectId process-unique value: " + err.Error())
+	}
+	return b
+}()
+
+var objectIdCounter = func() uint32 {
+	var b [3]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("polodb: failed to seed ObjectId counter: " + err.Error())
+	}
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}()
+
+// NewObjectId generates a fresh ObjectId from the current time, this
+// process's random value, and the next value of the shared counter.
+func NewObjectId() ObjectId {
+	var id ObjectId
+	binary.BigEndian.PutUint32(id[0:4], uint32(time.Now().Unix()))
+	copy(id[4:9], processUnique[:])
+
+	c := atomic.AddUint32(&objectIdCounter, 1) & 0xFFFFFF
+	id[9] = byte(c >> 16)
+	id[10] = byte(c >> 8)
+	id[11] = byte(c)
+	return id
+}
+
+// ObjectIdFromHex parses a 24-character hex string into an ObjectId.
+func ObjectIdFromHex(s string) (ObjectId, error) {
+	var id ObjectId
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return id, err
+	}
+	if len(b) != len(id) {
+		return id, errors.New("polodb: invalid ObjectId hex length\n")
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// Hex returns the lowercase hex encoding of id.
+func (id ObjectId) Hex() string {
+	return hex.EncodeToString(id[:])
+}
+
+// String implements fmt.Stringer.
+func (id ObjectId) String() string {
+	return id.Hex()
+}
+
+// IsZero reports whether id is the zero value.
+func (id ObjectId) IsZero() bool {
+	return id == ObjectId{}
+}
+
+// Equal reports whether id and other identify the same document.
+func (id ObjectId) Equal(other ObjectId) bool {
+	return id == other
+}
+
+func (id ObjectId) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.Hex())
+}
+
+func (id *ObjectId) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ObjectIdFromHex(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// MarshalBSON returns the raw 12 bytes backing id, for callers building
+// documents by hand instead of going through Marshal.
+func (id ObjectId) MarshalBSON() ([]byte, error) {
+	out := make([]byte, len(id))
+	copy(out, id[:])
+	return out, nil
+}
+
+// toC converts id into a freshly-allocated *C.DbObjectId. The caller owns
+// the result and must release it with C.PLDB_free_object_id.
+func (id ObjectId) toC() *C.DbObjectId {
+	return C.PLDB_object_id_from_bytes((*C.char)(unsafe.Pointer(&id[0])))
+}
+
+// objectIdFromValue copies the object id held by val into a Go ObjectId
+// and immediately frees the C value, so callers never have to manage the
+// underlying *C.DbObjectId themselves.
+func objectIdFromValue(val *C.DbValue) (ObjectId, error) {
+	var raw *C.DbObjectId
+	if resCode := C.PLDB_value_get_object_id(val, &raw); resCode < 0 {
+		return ObjectId{}, errors.New("DbValue get object id error")
+	}
+	defer C.PLDB_free_object_id(raw)
+
+	var id ObjectId
+	C.PLDB_object_id_to_bytes(raw, (*C.char)(unsafe.Pointer(&id[0])))
+	return id, nil
+}
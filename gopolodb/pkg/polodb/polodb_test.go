@@ -0,0 +1,297 @@
+package polodb
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Person is the round-trip fixture shared by the tests below.
+type Person struct {
+	Name string `polodb:"name"`
+	Age  int    `polodb:"age"`
+}
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestInsertFindUpdateDelete exercises the basic document lifecycle
+// against a real PoloDB file. It would have caught a Cursor.Next that
+// never steps the handle on its first call: Find and FindOne both
+// build on FindCursor, so a silently-empty cursor fails this test at
+// the first FindOne.
+func TestInsertFindUpdateDelete(t *testing.T) {
+	db := openTestDB(t)
+	col, err := db.CreateCollection("people")
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	if err := col.Insert(&Person{Name: "joseph", Age: 13}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	var got Person
+	if err := col.FindOne(map[string]interface{}{"name": "joseph"}, &got); err != nil {
+		t.Fatalf("FindOne: %v", err)
+	}
+	if got.Name != "joseph" || got.Age != 13 {
+		t.Fatalf("FindOne = %+v, want {joseph 13}", got)
+	}
+
+	if err := col.Update(
+		map[string]interface{}{"name": "joseph"},
+		map[string]interface{}{"$set": map[string]interface{}{"age": 14}},
+	); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	var updated Person
+	if err := col.FindOne(map[string]interface{}{"name": "joseph"}, &updated); err != nil {
+		t.Fatalf("FindOne after Update: %v", err)
+	}
+	if updated.Age != 14 {
+		t.Fatalf("Age after Update = %d, want 14", updated.Age)
+	}
+
+	count, err := col.Count(map[string]interface{}{"name": "joseph"})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Count = %d, want 1", count)
+	}
+
+	deleted, err := col.Delete(map[string]interface{}{"name": "joseph"})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("Delete = %d, want 1", deleted)
+	}
+
+	if count, err := col.Count(map[string]interface{}{"name": "joseph"}); err != nil {
+		t.Fatalf("Count after Delete: %v", err)
+	} else if count != 0 {
+		t.Fatalf("Count after Delete = %d, want 0", count)
+	}
+}
+
+// TestFindCursor inserts several documents and iterates them with a raw
+// Cursor, checking that Next reports every row before reporting none and
+// that Err stays nil for a clean iteration.
+func TestFindCursor(t *testing.T) {
+	db := openTestDB(t)
+	col, err := db.CreateCollection("people")
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	want := []Person{{Name: "a", Age: 1}, {Name: "b", Age: 2}, {Name: "c", Age: 3}}
+	for _, p := range want {
+		if err := col.Insert(&p); err != nil {
+			t.Fatalf("Insert(%+v): %v", p, err)
+		}
+	}
+
+	cur, err := col.FindCursor(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("FindCursor: %v", err)
+	}
+	defer cur.Close()
+
+	var got []Person
+	for cur.Next() {
+		var p Person
+		if err := cur.Decode(&p); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got = append(got, p)
+	}
+	if err := cur.Err(); err != nil {
+		t.Fatalf("cursor iteration: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(got), len(want))
+	}
+}
+
+// TestTwoCollections checks that a second collection's documents stay
+// isolated from the first's, guarding against Find/Insert/etc. hard-coding
+// a single collection's id/metaVersion instead of using the one the
+// caller named.
+func TestTwoCollections(t *testing.T) {
+	db := openTestDB(t)
+	people, err := db.CreateCollection("people")
+	if err != nil {
+		t.Fatalf("CreateCollection(people): %v", err)
+	}
+	pets, err := db.CreateCollection("pets")
+	if err != nil {
+		t.Fatalf("CreateCollection(pets): %v", err)
+	}
+
+	if err := people.Insert(&Person{Name: "joseph", Age: 13}); err != nil {
+		t.Fatalf("Insert into people: %v", err)
+	}
+
+	count, err := pets.Count(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Count pets: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("pets count = %d, want 0 (people's document leaked into pets)", count)
+	}
+}
+
+// Address is the type of Employee's Home field below, to exercise nested
+// struct/slice marshaling: a sub-document field plus a slice of scalars.
+type Address struct {
+	City string `polodb:"city"`
+}
+
+type Employee struct {
+	Name  string   `polodb:"name"`
+	Home  Address  `polodb:"home"`
+	Tags  []string `polodb:"tags"`
+	Badge ObjectId `polodb:"badge"`
+}
+
+// TestNestedStructAndSlice round-trips a sub-document field and a slice
+// field, the case the reflect-based mapper exists to handle.
+func TestNestedStructAndSlice(t *testing.T) {
+	db := openTestDB(t)
+	col, err := db.CreateCollection("employees")
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	badge := NewObjectId()
+	want := Employee{
+		Name:  "ada",
+		Home:  Address{City: "london"},
+		Tags:  []string{"eng", "lead"},
+		Badge: badge,
+	}
+	if err := col.Insert(&want); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	var got Employee
+	if err := col.FindOne(map[string]interface{}{"name": "ada"}, &got); err != nil {
+		t.Fatalf("FindOne: %v", err)
+	}
+	if got.Home.City != want.Home.City {
+		t.Fatalf("Home.City = %q, want %q", got.Home.City, want.Home.City)
+	}
+	if len(got.Tags) != len(want.Tags) || got.Tags[0] != want.Tags[0] || got.Tags[1] != want.Tags[1] {
+		t.Fatalf("Tags = %v, want %v", got.Tags, want.Tags)
+	}
+	if !got.Badge.Equal(want.Badge) {
+		t.Fatalf("Badge = %v, want %v", got.Badge, want.Badge)
+	}
+}
+
+// TestQueryOperator checks a $gt filter actually narrows the result set,
+// rather than every document matching regardless of the operator.
+func TestQueryOperator(t *testing.T) {
+	db := openTestDB(t)
+	col, err := db.CreateCollection("people")
+	if err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	for _, p := range []Person{{Name: "a", Age: 10}, {Name: "b", Age: 20}, {Name: "c", Age: 30}} {
+		if err := col.Insert(&p); err != nil {
+			t.Fatalf("Insert(%+v): %v", p, err)
+		}
+	}
+
+	count, err := col.Count(map[string]interface{}{
+		"age": map[string]interface{}{OpGt: 15},
+	})
+	if err != nil {
+		t.Fatalf("Count with $gt: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Count with age $gt 15 = %d, want 2", count)
+	}
+}
+
+// TestTransactionCommit checks that a committed transaction's writes are
+// visible afterward through the plain (non-transactional) DB handle.
+func TestTransactionCommit(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.CreateCollection("people"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	txCol, err := tx.Collection("people")
+	if err != nil {
+		t.Fatalf("tx.Collection: %v", err)
+	}
+	if err := txCol.Insert(&Person{Name: "joseph", Age: 13}); err != nil {
+		t.Fatalf("Insert in tx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	col, err := db.Collection("people")
+	if err != nil {
+		t.Fatalf("Collection: %v", err)
+	}
+	count, err := col.Count(map[string]interface{}{"name": "joseph"})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Count after commit = %d, want 1", count)
+	}
+}
+
+// TestTransactionRollback checks that a rolled-back transaction's writes
+// never become visible.
+func TestTransactionRollback(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.CreateCollection("people"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	tx, err := db.BeginTx()
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	txCol, err := tx.Collection("people")
+	if err != nil {
+		t.Fatalf("tx.Collection: %v", err)
+	}
+	if err := txCol.Insert(&Person{Name: "joseph", Age: 13}); err != nil {
+		t.Fatalf("Insert in tx: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	col, err := db.Collection("people")
+	if err != nil {
+		t.Fatalf("Collection: %v", err)
+	}
+	count, err := col.Count(map[string]interface{}{"name": "joseph"})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Count after rollback = %d, want 0 (rolled-back insert is visible)", count)
+	}
+}
@@ -0,0 +1,131 @@
+package polodb
+
+/*
+#cgo CFLAGS: -I../../lib
+#cgo LDFLAGS: -L../../lib -lpolodb
+#include "../../lib/polodb.h"
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// Query operator keys understood inside a filter or update document, e.g.
+//
+//	col.FindAll(map[string]interface{}{
+//		"age": map[string]interface{}{OpGte: 18, OpLt: 65},
+//	}, &results)
+//
+// These are plain document keys: Marshal encodes them like any other
+// nested document, so operators compose with $and/$or without special
+// casing in the marshaler.
+const (
+	OpGt    = "$gt"
+	OpGte   = "$gte"
+	OpLt    = "$lt"
+	OpLte   = "$lte"
+	OpIn    = "$in"
+	OpNin   = "$nin"
+	OpNe    = "$ne"
+	OpAnd   = "$and"
+	OpOr    = "$or"
+	OpRegex = "$regex"
+)
+
+// Update applies update to every document in the collection matching
+// filter.
+func (c *Collection) Update(filter interface{}, update interface{}) error {
+	queryDoc, err := Marshal(filter)
+	if err != nil {
+		return err
+	}
+	defer C.PLDB_free_doc(queryDoc)
+
+	updateDoc, err := Marshal(update)
+	if err != nil {
+		return err
+	}
+	defer C.PLDB_free_doc(updateDoc)
+
+	errCode := C.PLDB_update(c.db, c.id, c.metaVer, queryDoc, updateDoc)
+	if errCode < 0 {
+		return errors.New("Error updating database\n")
+	}
+	return nil
+}
+
+// Delete removes every document matching filter and returns how many were
+// deleted.
+func (c *Collection) Delete(filter interface{}) (int, error) {
+	queryDoc, err := Marshal(filter)
+	if err != nil {
+		return 0, err
+	}
+	defer C.PLDB_free_doc(queryDoc)
+
+	deleted := C.PLDB_delete(c.db, c.id, c.metaVer, queryDoc)
+	if deleted < 0 {
+		return 0, errors.New("Error deleting from database\n")
+	}
+	return int(deleted), nil
+}
+
+// Count returns the number of documents matching filter.
+func (c *Collection) Count(filter interface{}) (int, error) {
+	queryDoc, err := Marshal(filter)
+	if err != nil {
+		return 0, err
+	}
+	defer C.PLDB_free_doc(queryDoc)
+
+	var count C.longlong
+	errCode := C.PLDB_count(c.db, c.id, c.metaVer, queryDoc, &count)
+	if errCode < 0 {
+		return 0, errors.New("Error counting documents\n")
+	}
+	return int(count), nil
+}
+
+// IndexOptions controls how CreateIndex builds an index.
+type IndexOptions struct {
+	Unique bool
+}
+
+// CreateIndex builds an index over keys (a map of field name to sort
+// direction, e.g. map[string]interface{}{"age": 1}) on the collection.
+func (c *Collection) CreateIndex(keys interface{}, opts *IndexOptions) error {
+	keysDoc, err := Marshal(keys)
+	if err != nil {
+		return err
+	}
+	defer C.PLDB_free_doc(keysDoc)
+
+	cCol := C.CString(c.name)
+	defer C.free(unsafe.Pointer(cCol))
+
+	unique := C.bool(false)
+	if opts != nil {
+		unique = C.bool(opts.Unique)
+	}
+
+	errCode := C.PLDB_create_index(c.db, cCol, keysDoc, unique)
+	if errCode < 0 {
+		return errors.New("Error creating index\n")
+	}
+	return nil
+}
+
+// DropIndex removes the named index from the collection.
+func (c *Collection) DropIndex(indexName string) error {
+	cCol := C.CString(c.name)
+	defer C.free(unsafe.Pointer(cCol))
+	cIndex := C.CString(indexName)
+	defer C.free(unsafe.Pointer(cIndex))
+
+	errCode := C.PLDB_drop_index(c.db, cCol, cIndex)
+	if errCode < 0 {
+		return errors.New("Error dropping index\n")
+	}
+	return nil
+}
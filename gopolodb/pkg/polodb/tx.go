@@ -0,0 +1,144 @@
+package polodb
+
+/*
+#cgo CFLAGS: -I../../lib
+#cgo LDFLAGS: -L../../lib -lpolodb
+#include "../../lib/polodb.h"
+*/
+import "C"
+import "errors"
+
+// Tx is an in-flight PoloDB transaction. Its CreateCollection/Collection
+// methods return a TxCollection exposing the same Insert/Find/Update/
+// Delete surface as DB's, routed through the transactional C entry
+// points. A Tx must end with exactly one call to Commit or Rollback.
+type Tx struct {
+	db   *DB
+	done bool
+}
+
+// BeginTx starts a transaction on d. The DB's mutex is held for the
+// lifetime of the transaction, so other goroutines using d block until
+// the returned Tx is committed or rolled back.
+func (d *DB) BeginTx() (*Tx, error) {
+	d.mu.Lock()
+	if errCode := C.PLDB_start_transaction(d.db, 0); errCode < 0 {
+		d.mu.Unlock()
+		return nil, errors.New("Error starting transaction\n")
+	}
+	return &Tx{db: d}, nil
+}
+
+// WithTransaction runs fn inside a transaction, committing if fn returns
+// nil and rolling back if it returns an error or panics.
+func (d *DB) WithTransaction(fn func(tx *Tx) error) (err error) {
+	tx, err := d.BeginTx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Commit finishes the transaction, making its writes durable.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return errors.New("polodb: transaction already finished\n")
+	}
+	tx.done = true
+	defer tx.db.mu.Unlock()
+	if errCode := C.PLDB_commit(tx.db.db); errCode < 0 {
+		return errors.New("Error committing transaction\n")
+	}
+	return nil
+}
+
+// Rollback discards every write made during the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return errors.New("polodb: transaction already finished\n")
+	}
+	tx.done = true
+	defer tx.db.mu.Unlock()
+	if errCode := C.PLDB_rollback(tx.db.db); errCode < 0 {
+		return errors.New("Error rolling back transaction\n")
+	}
+	return nil
+}
+
+// TxCollection is a handle to one named collection, scoped to a single
+// transaction. It exposes Collection's Insert, Find, FindOne, FindAll,
+// Update and Delete; FindCursor, Count, CreateIndex and DropIndex are not
+// available on it. The DB's mutex is already held for the whole
+// transaction, so unlike DBCollection it does no locking of its own.
+type TxCollection struct {
+	tx  *Tx
+	col *Collection
+}
+
+// CreateCollection creates colName within tx and returns a handle to it.
+func (tx *Tx) CreateCollection(colName string) (*TxCollection, error) {
+	if err := tx.db.checkWritable(); err != nil {
+		return nil, err
+	}
+	col, err := tx.db.db.CreateCollection(colName)
+	if err != nil {
+		return nil, err
+	}
+	return &TxCollection{tx: tx, col: col}, nil
+}
+
+// Collection looks up a collection created in an earlier session and
+// returns a handle to it, scoped to tx.
+func (tx *Tx) Collection(colName string) (*TxCollection, error) {
+	col, err := tx.db.db.Collection(colName)
+	if err != nil {
+		return nil, err
+	}
+	return &TxCollection{tx: tx, col: col}, nil
+}
+
+func (c *TxCollection) Insert(values interface{}) error {
+	if err := c.tx.db.checkWritable(); err != nil {
+		return err
+	}
+	return c.col.Insert(values)
+}
+
+func (c *TxCollection) Find(filter interface{}, result interface{}) error {
+	return c.col.Find(filter, result)
+}
+
+func (c *TxCollection) FindOne(filter interface{}, result interface{}) error {
+	return c.col.FindOne(filter, result)
+}
+
+func (c *TxCollection) FindAll(filter interface{}, results interface{}) error {
+	return c.col.FindAll(filter, results)
+}
+
+func (c *TxCollection) Update(filter interface{}, update interface{}) error {
+	if err := c.tx.db.checkWritable(); err != nil {
+		return err
+	}
+	return c.col.Update(filter, update)
+}
+
+func (c *TxCollection) Delete(filter interface{}) (int, error) {
+	if err := c.tx.db.checkWritable(); err != nil {
+		return 0, err
+	}
+	return c.col.Delete(filter)
+}